@@ -0,0 +1,116 @@
+// Package broker replaces O(N) hub fanout with topic subscriptions: a
+// client only receives an event published to a topic if it has registered
+// interest in that topic, instead of every connected client being walked.
+// Currently only collection_changed ("collection:{uuid}") and kicks
+// (KickTopic) go through the broker; other cluster events are still sent to
+// every connected client by the hub.
+package broker
+
+import "sync"
+
+// Client is the subset of a websocket client the broker needs to deliver
+// messages, kept minimal so this package does not depend on the websocket
+// package (which in turn depends on the broker to dispatch subscriptions).
+type Client interface {
+	LoginId() int64
+	IsAdmin() bool
+	Send(message []byte)
+
+	// Kick disconnects the client. Called by Kick() for subscribers of
+	// KickTopic; implementations are expected to make this non-blocking
+	// (e.g. by handing off to a dedicated hub goroutine) since it may be
+	// invoked while holding the broker's lock.
+	Kick()
+}
+
+// KickTopic is subscribed to by every connected client and is always
+// delivered, preserving the previous global Kick/KickNonAdmin broadcast
+// semantics regardless of what else a client has subscribed to.
+const KickTopic = "__kick__"
+
+var (
+	mx     sync.RWMutex
+	topics = make(map[string]map[Client]struct{})
+)
+
+// Subscribe registers c as interested in topic.
+func Subscribe(topic string, c Client) {
+	mx.Lock()
+	defer mx.Unlock()
+
+	if _, exists := topics[topic]; !exists {
+		topics[topic] = make(map[Client]struct{})
+	}
+	topics[topic][c] = struct{}{}
+}
+
+// Unsubscribe removes c's interest in topic.
+func Unsubscribe(topic string, c Client) {
+	mx.Lock()
+	defer mx.Unlock()
+	removeLocked(topic, c)
+}
+
+// UnsubscribeAll removes c from every topic, called once a client
+// disconnects so it is not kept alive by a stale subscription.
+func UnsubscribeAll(c Client) {
+	mx.Lock()
+	defer mx.Unlock()
+
+	for topic := range topics {
+		removeLocked(topic, c)
+	}
+}
+
+func removeLocked(topic string, c Client) {
+	subs, exists := topics[topic]
+	if !exists {
+		return
+	}
+	delete(subs, c)
+	if len(subs) == 0 {
+		delete(topics, topic)
+	}
+}
+
+// Publish fans message out to every current subscriber of topic. message is
+// expected to already be the fully marshalled payload, computed once by the
+// caller, so every subscriber shares the same []byte rather than each
+// triggering its own marshalling.
+func Publish(topic string, message []byte) {
+	mx.RLock()
+	defer mx.RUnlock()
+
+	for c := range topics[topic] {
+		c.Send(message)
+	}
+}
+
+// PublishFiltered is Publish, restricted to subscribers for which keep
+// returns true - used when a topic's subscribers are broader than the
+// audience one particular event is scoped to (e.g. a collection_changed
+// event scoped to a single login).
+func PublishFiltered(topic string, message []byte, keep func(c Client) bool) {
+	mx.RLock()
+	defer mx.RUnlock()
+
+	for c := range topics[topic] {
+		if keep(c) {
+			c.Send(message)
+		}
+	}
+}
+
+// Kick disconnects every current subscriber of KickTopic, optionally
+// limited to non-admin logins (KickNonAdmin semantics).
+func Kick(nonAdminOnly bool) {
+	mx.RLock()
+	defer mx.RUnlock()
+
+	for c := range topics[KickTopic] {
+		if nonAdminOnly && c.IsAdmin() {
+			continue
+		}
+		c.Kick()
+	}
+}
@@ -0,0 +1,125 @@
+package broker
+
+import "testing"
+
+type testClient struct {
+	loginId int64
+	admin   bool
+	got     [][]byte
+	kicked  bool
+}
+
+func (c *testClient) LoginId() int64      { return c.loginId }
+func (c *testClient) IsAdmin() bool       { return c.admin }
+func (c *testClient) Send(message []byte) { c.got = append(c.got, message) }
+func (c *testClient) Kick()               { c.kicked = true }
+
+func TestPublishOnlyReachesSubscribers(t *testing.T) {
+	defer resetTopics()
+
+	subscribed := &testClient{loginId: 1}
+	unsubscribed := &testClient{loginId: 2}
+
+	Subscribe("collection:a", subscribed)
+	Publish("collection:a", []byte("hello"))
+
+	if len(subscribed.got) != 1 {
+		t.Fatalf("expected subscribed client to receive 1 message, got %d", len(subscribed.got))
+	}
+	if len(unsubscribed.got) != 0 {
+		t.Fatalf("expected unsubscribed client to receive nothing, got %d", len(unsubscribed.got))
+	}
+}
+
+func TestUnsubscribeRemovesInterest(t *testing.T) {
+	defer resetTopics()
+
+	c := &testClient{loginId: 1}
+	Subscribe("schema", c)
+	Unsubscribe("schema", c)
+	Publish("schema", []byte("changed"))
+
+	if len(c.got) != 0 {
+		t.Fatalf("expected no messages after unsubscribe, got %d", len(c.got))
+	}
+}
+
+func TestUnsubscribeAllRemovesFromEveryTopic(t *testing.T) {
+	defer resetTopics()
+
+	c := &testClient{loginId: 1}
+	Subscribe("a", c)
+	Subscribe("b", c)
+	UnsubscribeAll(c)
+
+	Publish("a", []byte("x"))
+	Publish("b", []byte("y"))
+
+	if len(c.got) != 0 {
+		t.Fatalf("expected no messages after UnsubscribeAll, got %d", len(c.got))
+	}
+	if len(topics) != 0 {
+		t.Fatalf("expected empty topics no longer tracked, got %d entries", len(topics))
+	}
+}
+
+func TestPublishFilteredOnlyReachesKeptSubscribers(t *testing.T) {
+	defer resetTopics()
+
+	a := &testClient{loginId: 1}
+	b := &testClient{loginId: 2}
+	Subscribe("collection:a", a)
+	Subscribe("collection:a", b)
+
+	PublishFiltered("collection:a", []byte("hello"), func(c Client) bool {
+		return c.LoginId() == 1
+	})
+
+	if len(a.got) != 1 {
+		t.Fatalf("expected kept subscriber to receive 1 message, got %d", len(a.got))
+	}
+	if len(b.got) != 0 {
+		t.Fatalf("expected filtered-out subscriber to receive nothing, got %d", len(b.got))
+	}
+}
+
+func TestKickNonAdminOnlySkipsAdmins(t *testing.T) {
+	defer resetTopics()
+
+	admin := &testClient{loginId: 1, admin: true}
+	user := &testClient{loginId: 2}
+	Subscribe(KickTopic, admin)
+	Subscribe(KickTopic, user)
+
+	Kick(true)
+
+	if admin.kicked {
+		t.Fatal("expected admin to not be kicked by KickNonAdmin")
+	}
+	if !user.kicked {
+		t.Fatal("expected non-admin to be kicked by KickNonAdmin")
+	}
+}
+
+func TestKickAllKicksEveryone(t *testing.T) {
+	defer resetTopics()
+
+	admin := &testClient{loginId: 1, admin: true}
+	user := &testClient{loginId: 2}
+	Subscribe(KickTopic, admin)
+	Subscribe(KickTopic, user)
+
+	Kick(false)
+
+	if !admin.kicked || !user.kicked {
+		t.Fatal("expected Kick(false) to kick every subscriber, admin included")
+	}
+}
+
+// resetTopics clears global subscription state between tests, since the
+// package keeps it at package scope rather than behind a constructor.
+func resetTopics() {
+	mx.Lock()
+	defer mx.Unlock()
+	topics = make(map[string]map[Client]struct{})
+}
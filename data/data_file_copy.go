@@ -1,16 +1,17 @@
 package data
 
 import (
+	"context"
 	"fmt"
 	"r3/db"
+	"r3/filestore"
 	"r3/schema"
-	"r3/tools"
 	"r3/types"
 
 	"github.com/gofrs/uuid"
 )
 
-func CopyFiles(loginId int64, srcAttributeId uuid.UUID, srcFileIds []uuid.UUID,
+func CopyFiles(ctx context.Context, loginId int64, srcAttributeId uuid.UUID, srcFileIds []uuid.UUID,
 	srcRecordId int64, dstAttributeId uuid.UUID) ([]types.DataGetValueFile, error) {
 
 	files := make([]types.DataGetValueFile, 0)
@@ -56,7 +57,7 @@ func CopyFiles(loginId int64, srcAttributeId uuid.UUID, srcFileIds []uuid.UUID,
 
 	// check if all requested files exist before starting
 	for _, f := range files {
-		exists, err := tools.Exists(GetFilePathVersion(srcAttributeId, f.Id, f.Version))
+		_, exists, err := filestore.Stat(ctx, filestore.GetFileKeyVersion(srcAttributeId, f.Id, f.Version))
 		if err != nil {
 			return files, err
 		}
@@ -73,10 +74,10 @@ func CopyFiles(loginId int64, srcAttributeId uuid.UUID, srcFileIds []uuid.UUID,
 			return files, err
 		}
 
-		srcPath := GetFilePathVersion(srcAttributeId, f.Id, f.Version)
-		dstPath := GetFilePathVersion(dstAttributeId, idNew, 0)
+		srcKey := filestore.GetFileKeyVersion(srcAttributeId, f.Id, f.Version)
+		dstKey := filestore.GetFileKeyVersion(dstAttributeId, idNew, 0)
 
-		if err := tools.FileCopy(srcPath, dstPath, false); err != nil {
+		if err := filestore.Copy(ctx, srcKey, dstKey); err != nil {
 			return files, err
 		}
 
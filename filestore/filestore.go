@@ -0,0 +1,143 @@
+// Package filestore abstracts where instance_file attachments physically
+// live. Everything that used to reach straight for local filesystem paths
+// via data.GetFilePathVersion now goes through here instead, so the same
+// code works whether files sit on local disk or in an S3/MinIO bucket.
+package filestore
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"r3/filestore/local"
+	"r3/filestore/s3"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+// Driver is implemented once per storage backend (local disk, S3/MinIO, ...).
+// Keys are backend-agnostic paths, derived the same way local file paths
+// used to be (see data.GetFilePathVersion).
+type Driver interface {
+	Put(ctx context.Context, key string, r io.Reader, sizeBytes int64) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+
+	// Stat returns the stored object's size and whether it exists at all.
+	Stat(ctx context.Context, key string) (sizeByte int64, exists bool, err error)
+	Delete(ctx context.Context, key string) error
+	Copy(ctx context.Context, srcKey string, dstKey string) error
+
+	// Presign returns a short-lived URL for direct up-/download, bypassing
+	// the r3 process. Not every driver supports this; for the local driver,
+	// filestore.Presign returns ErrPresignUnsupported.
+	Presign(ctx context.Context, key string, method string, expiry time.Duration) (string, error)
+}
+
+// Config mirrors the "Storage.*" config keys of an installation.
+type Config struct {
+	Driver     string // "local" (default) or "s3"
+	PathPrefix string // local: base directory: s3: key prefix
+
+	// s3 driver only
+	Endpoint  string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	UseSSL    bool
+	Region    string
+}
+
+var (
+	ErrPresignUnsupported = fmt.Errorf("storage driver does not support presigned URLs")
+
+	// active is read by every file operation (from arbitrary request
+	// goroutines) and written by Init, which may run while those reads are
+	// in flight - so it is an
+	// atomic.Pointer rather than a plain Driver var, even though the
+	// interface value itself never mutates once stored.
+	active atomic.Pointer[Driver]
+)
+
+// ensureActive defaults active to the local driver, rooted at the working
+// directory, if the installation startup path never called Init - so a
+// config that omits "Storage.*" entirely (or code that runs before startup
+// gets around to it) still resolves to a working driver instead of leaving
+// active nil and panicking the first time a file operation runs. It only
+// ever installs a driver into an empty active, so a concurrent real Init
+// call cannot be undone by it.
+func ensureActive() Driver {
+	if drv := active.Load(); drv != nil {
+		return *drv
+	}
+	drv := local.New("")
+	active.CompareAndSwap(nil, &drv)
+	return *active.Load()
+}
+
+// Init selects and configures the active driver from the installation's
+// "Storage.*" settings, replacing any driver selected before. Nothing in
+// this package calls it; until it has been called, file operations use the
+// local driver rooted at the working directory (see ensureActive).
+func Init(cfg Config) error {
+	var drv Driver
+
+	switch cfg.Driver {
+	case "", "local":
+		drv = local.New(cfg.PathPrefix)
+	case "s3":
+		s3Drv, err := s3.New(s3.Config{
+			Endpoint:   cfg.Endpoint,
+			Bucket:     cfg.Bucket,
+			AccessKey:  cfg.AccessKey,
+			SecretKey:  cfg.SecretKey,
+			UseSSL:     cfg.UseSSL,
+			Region:     cfg.Region,
+			PathPrefix: cfg.PathPrefix,
+		})
+		if err != nil {
+			return err
+		}
+		drv = s3Drv
+	default:
+		return fmt.Errorf("unknown storage driver '%s'", cfg.Driver)
+	}
+
+	active.Store(&drv)
+	return nil
+}
+
+func Put(ctx context.Context, key string, r io.Reader, sizeBytes int64) error {
+	return ensureActive().Put(ctx, key, r, sizeBytes)
+}
+
+func Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	return ensureActive().Get(ctx, key)
+}
+
+func Stat(ctx context.Context, key string) (sizeByte int64, exists bool, err error) {
+	return ensureActive().Stat(ctx, key)
+}
+
+func Delete(ctx context.Context, key string) error {
+	return ensureActive().Delete(ctx, key)
+}
+
+func Copy(ctx context.Context, srcKey string, dstKey string) error {
+	return ensureActive().Copy(ctx, srcKey, dstKey)
+}
+
+func Presign(ctx context.Context, key string, method string, expiry time.Duration) (string, error) {
+	drv := ensureActive()
+	if _, isLocal := drv.(*local.Driver); isLocal {
+		return "", ErrPresignUnsupported
+	}
+	return drv.Presign(ctx, key, method, expiry)
+}
+
+// GetFileKeyVersion builds the backend-agnostic key for one file version,
+// replacing the local-path-only data.GetFilePathVersion for any code that
+// now goes through a driver.
+func GetFileKeyVersion(attributeId uuid.UUID, fileId uuid.UUID, version int64) string {
+	return fmt.Sprintf("%s/%s_%d", attributeId, fileId, version)
+}
@@ -0,0 +1,78 @@
+// Package local implements filestore.Driver on top of the instance's own
+// filesystem, the original (and still default) storage backend.
+package local
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"r3/tools"
+	"time"
+)
+
+type Driver struct {
+	pathPrefix string
+}
+
+// New returns a local filesystem driver rooted at pathPrefix. Keys are
+// joined onto pathPrefix the same way data.GetFilePathVersion used to build
+// absolute paths.
+func New(pathPrefix string) *Driver {
+	return &Driver{pathPrefix: pathPrefix}
+}
+
+func (d *Driver) resolve(key string) string {
+	return filepath.Join(d.pathPrefix, key)
+}
+
+func (d *Driver) Put(ctx context.Context, key string, r io.Reader, sizeBytes int64) error {
+	path := d.resolve(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(f, r)
+	return err
+}
+
+func (d *Driver) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	return os.Open(d.resolve(key))
+}
+
+func (d *Driver) Stat(ctx context.Context, key string) (int64, bool, error) {
+	info, err := os.Stat(d.resolve(key))
+	if os.IsNotExist(err) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return info.Size(), true, nil
+}
+
+func (d *Driver) Delete(ctx context.Context, key string) error {
+	err := os.Remove(d.resolve(key))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (d *Driver) Copy(ctx context.Context, srcKey string, dstKey string) error {
+	dstPath := d.resolve(dstKey)
+	if err := os.MkdirAll(filepath.Dir(dstPath), 0750); err != nil {
+		return err
+	}
+	return tools.FileCopy(d.resolve(srcKey), dstPath, false)
+}
+
+func (d *Driver) Presign(ctx context.Context, key string, method string, expiry time.Duration) (string, error) {
+	return "", fmt.Errorf("local storage driver does not support presigned URLs")
+}
@@ -0,0 +1,76 @@
+package local
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+)
+
+func TestPutGetStatDeleteRoundTrip(t *testing.T) {
+	d := New(t.TempDir())
+	ctx := context.Background()
+	key := "attr/file_1"
+	content := []byte("hello world")
+
+	if err := d.Put(ctx, key, bytes.NewReader(content), int64(len(content))); err != nil {
+		t.Fatalf("Put returned error: %v", err)
+	}
+
+	size, exists, err := d.Stat(ctx, key)
+	if err != nil {
+		t.Fatalf("Stat returned error: %v", err)
+	}
+	if !exists {
+		t.Fatal("expected key to exist after Put")
+	}
+	if size != int64(len(content)) {
+		t.Fatalf("expected size %d, got %d", len(content), size)
+	}
+
+	r, err := d.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+	defer r.Close()
+
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("failed to read content: %v", err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Fatalf("expected content %q, got %q", content, got)
+	}
+
+	if err := d.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete returned error: %v", err)
+	}
+
+	_, exists, err = d.Stat(ctx, key)
+	if err != nil {
+		t.Fatalf("Stat returned error: %v", err)
+	}
+	if exists {
+		t.Fatal("expected key to no longer exist after Delete")
+	}
+}
+
+func TestStatMissingKeyIsNotAnError(t *testing.T) {
+	d := New(t.TempDir())
+
+	_, exists, err := d.Stat(context.Background(), "does/not_exist")
+	if err != nil {
+		t.Fatalf("expected no error for a missing key, got %v", err)
+	}
+	if exists {
+		t.Fatal("expected exists to be false for a missing key")
+	}
+}
+
+func TestDeleteMissingKeyIsNotAnError(t *testing.T) {
+	d := New(t.TempDir())
+
+	if err := d.Delete(context.Background(), "does/not_exist"); err != nil {
+		t.Fatalf("expected no error deleting a missing key, got %v", err)
+	}
+}
@@ -0,0 +1,113 @@
+package filestore
+
+import (
+	"context"
+	"fmt"
+	"r3/db"
+	"r3/filestore/local"
+	"r3/log"
+	"r3/schema"
+
+	"github.com/gofrs/uuid"
+)
+
+const handlerContextMigrate = "filestore_migrate"
+
+// MigrateLocalToActive walks every instance_file.* table and uploads files
+// still sitting on local disk to the currently configured driver (e.g. S3).
+// It is safe to re-run: files already present at the destination are
+// skipped. Intended to be triggered once, manually, after switching
+// Storage.Driver away from "local".
+func MigrateLocalToActive(ctx context.Context, localPathPrefix string) error {
+	src := local.New(localPathPrefix)
+
+	attributeIds, err := getFileAttributeIds(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, attributeId := range attributeIds {
+		if err := migrateAttribute(ctx, src, attributeId); err != nil {
+			return fmt.Errorf("failed to migrate attribute '%s': %w", attributeId, err)
+		}
+	}
+	return nil
+}
+
+func getFileAttributeIds(ctx context.Context) ([]uuid.UUID, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT id
+		FROM app.attribute
+		WHERE content = 'files'
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make([]uuid.UUID, 0)
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func migrateAttribute(ctx context.Context, src *local.Driver, attributeId uuid.UUID) error {
+	relVersion := schema.GetFilesTableNameVersions(attributeId)
+
+	rows, err := db.Pool.Query(ctx, fmt.Sprintf(`
+		SELECT file_id, version
+		FROM instance_file."%s"
+	`, relVersion))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	type fileVersion struct {
+		fileId  uuid.UUID
+		version int64
+	}
+	versions := make([]fileVersion, 0)
+	for rows.Next() {
+		var fv fileVersion
+		if err := rows.Scan(&fv.fileId, &fv.version); err != nil {
+			return err
+		}
+		versions = append(versions, fv)
+	}
+	rows.Close()
+
+	for _, fv := range versions {
+		key := GetFileKeyVersion(attributeId, fv.fileId, fv.version)
+
+		if _, exists, err := Stat(ctx, key); err != nil {
+			return err
+		} else if exists {
+			continue // already migrated
+		}
+
+		r, err := src.Get(ctx, key)
+		if err != nil {
+			return err
+		}
+
+		size, _, err := src.Stat(ctx, key)
+		if err != nil {
+			r.Close()
+			return err
+		}
+
+		err = Put(ctx, key, r, size)
+		r.Close()
+		if err != nil {
+			return err
+		}
+		log.Info(handlerContextMigrate, fmt.Sprintf("migrated file '%s' to active storage driver", key))
+	}
+	return nil
+}
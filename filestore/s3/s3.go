@@ -0,0 +1,102 @@
+// Package s3 implements filestore.Driver against any S3/MinIO-compatible
+// object storage, letting large installations move instance_file content
+// off the application server's local disk.
+package s3
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"path"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// Config holds the "Storage.*" settings relevant to the S3 driver.
+type Config struct {
+	Endpoint   string
+	Bucket     string
+	AccessKey  string
+	SecretKey  string
+	UseSSL     bool
+	Region     string
+	PathPrefix string
+}
+
+type Driver struct {
+	client *minio.Client
+	bucket string
+	prefix string
+}
+
+// New connects to the configured S3/MinIO endpoint. It does not verify the
+// bucket exists; that surfaces on first use like any other driver error.
+func New(cfg Config) (*Driver, error) {
+	client, err := minio.New(cfg.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+		Secure: cfg.UseSSL,
+		Region: cfg.Region,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Driver{client: client, bucket: cfg.Bucket, prefix: cfg.PathPrefix}, nil
+}
+
+func (d *Driver) objectKey(key string) string {
+	return path.Join(d.prefix, key)
+}
+
+func (d *Driver) Put(ctx context.Context, key string, r io.Reader, sizeBytes int64) error {
+	_, err := d.client.PutObject(ctx, d.bucket, d.objectKey(key), r, sizeBytes,
+		minio.PutObjectOptions{})
+	return err
+}
+
+func (d *Driver) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	return d.client.GetObject(ctx, d.bucket, d.objectKey(key), minio.GetObjectOptions{})
+}
+
+func (d *Driver) Stat(ctx context.Context, key string) (int64, bool, error) {
+	info, err := d.client.StatObject(ctx, d.bucket, d.objectKey(key), minio.StatObjectOptions{})
+	if err != nil {
+		if errResp := minio.ToErrorResponse(err); errResp.Code == "NoSuchKey" {
+			return 0, false, nil
+		}
+		return 0, false, err
+	}
+	return info.Size, true, nil
+}
+
+func (d *Driver) Delete(ctx context.Context, key string) error {
+	return d.client.RemoveObject(ctx, d.bucket, d.objectKey(key), minio.RemoveObjectOptions{})
+}
+
+// Copy uses MinIO's server-side CopyObject so file bytes never round-trip
+// through the r3 process, unlike the local driver's plain file copy.
+func (d *Driver) Copy(ctx context.Context, srcKey string, dstKey string) error {
+	src := minio.CopySrcOptions{Bucket: d.bucket, Object: d.objectKey(srcKey)}
+	dst := minio.CopyDestOptions{Bucket: d.bucket, Object: d.objectKey(dstKey)}
+	_, err := d.client.CopyObject(ctx, dst, src)
+	return err
+}
+
+func (d *Driver) Presign(ctx context.Context, key string, method string, expiry time.Duration) (string, error) {
+	var u *url.URL
+	var err error
+
+	switch method {
+	case "GET":
+		u, err = d.client.PresignedGetObject(ctx, d.bucket, d.objectKey(key), expiry, url.Values{})
+	case "PUT":
+		u, err = d.client.PresignedPutObject(ctx, d.bucket, d.objectKey(key), expiry)
+	default:
+		return "", minio.ErrInvalidArgument("unsupported presign method '" + method + "'")
+	}
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
@@ -3,17 +3,25 @@ package websocket
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
+	"r3/broker"
 	"r3/bruteforce"
 	"r3/cache"
 	"r3/cluster"
+	"r3/db"
+	"r3/filestore"
 	"r3/handler"
 	"r3/log"
+	"r3/operations"
 	"r3/request"
+	"r3/request/scheduler"
+	"r3/setting"
 	"r3/types"
-	"sync"
+	"strings"
+	"time"
 
 	"github.com/gofrs/uuid"
 	"github.com/gorilla/websocket"
@@ -28,7 +36,7 @@ type clientType struct {
 	fixedToken bool               // logged in with fixed token (limited access, only auth and server messages)
 	loginId    int64              // client login ID, 0 = not logged in yet
 	noAuth     bool               // logged in without authentication (public auth, username only)
-	write_mx   sync.Mutex         // to force sequential writes
+	send       chan []byte        // outgoing messages, drained by a single writer goroutine
 	ws         *websocket.Conn    // websocket connection
 }
 
@@ -41,10 +49,25 @@ type hubType struct {
 	clientDel chan *clientType // delete client from hub
 }
 
+const (
+	// keepalive: if no pong arrives within pongWait, the
+	// connection is considered dead; pings are sent often enough to notice
+	// in time
+	pongWait   = 60 * time.Second
+	pingPeriod = (pongWait * 9) / 10
+	writeWait  = 10 * time.Second
+
+	// outgoing messages queued per client before it is considered too slow
+	// to keep up and is dropped, rather than piling up write goroutines
+	sendQueueSize = 256
+)
+
 var (
 	clientUpgrader = websocket.Upgrader{
-		ReadBufferSize:  1024,
-		WriteBufferSize: 1024}
+		ReadBufferSize:    1024,
+		WriteBufferSize:   1024,
+		EnableCompression: true, // negotiate permessage-deflate with the client
+	}
 
 	handlerContext = "websocket"
 
@@ -53,17 +76,12 @@ var (
 		clientAdd: make(chan *clientType),
 		clientDel: make(chan *clientType),
 	}
-
-	// limit concurrent requests to 10, regardless of client count
-	// known issue: if 10+ requests occur during schema reload, server hangs
-	// we traced the issue to the DB requests but there are no visible issues in Postgres or pgx
-	// 10 concurrently handled requests are more than reasonable - a workaround is fine for now
-	// we plan to upgrade to pgx v5 soon and will revisit the issue then
-	hubRequestLimit = make(chan bool, 10)
 )
 
 func StartBackgroundTasks() {
 	go hub.start()
+	operations.StartBackgroundTasks()
+	scheduler.StartBackgroundTasks()
 }
 
 func Handler(w http.ResponseWriter, r *http.Request) {
@@ -91,6 +109,9 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 
 	log.Info(handlerContext, fmt.Sprintf("new client connecting from %s", host))
 
+	// compress individual messages, not just negotiate the extension
+	ws.EnableWriteCompression(true)
+
 	// create global request context with abort function
 	ctx, ctxCancel := context.WithCancel(context.Background())
 
@@ -102,13 +123,47 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 		fixedToken: false,
 		loginId:    0,
 		noAuth:     false,
-		write_mx:   sync.Mutex{},
+		send:       make(chan []byte, sendQueueSize),
 		ws:         ws,
 	}
 
+	ws.SetReadDeadline(time.Now().Add(pongWait))
+	ws.SetPongHandler(func(string) error {
+		ws.SetReadDeadline(time.Now().Add(pongWait))
+		return nil
+	})
+
 	hub.clientAdd <- client
 
+	// every client is always reachable for kicks, regardless of what else
+	// it subscribes to
+	broker.Subscribe(broker.KickTopic, client)
+
 	go client.read()
+	go client.writePump()
+}
+
+// LoginId, IsAdmin and Send implement broker.Client so clientType can be
+// registered for topic subscriptions.
+func (client *clientType) LoginId() int64 {
+	return client.loginId
+}
+
+func (client *clientType) IsAdmin() bool {
+	return client.admin
+}
+
+func (client *clientType) Send(message []byte) {
+	client.write(message)
+}
+
+// Kick implements broker.Client. It hands off to hub.clientDel rather than
+// calling removeClient directly, since Kick may run on the hub's own
+// goroutine (via broker.Kick, called from hub.start) - removeClient itself
+// is hub.start-only state, so disconnecting must always go back through
+// that one goroutine, never be done inline from an arbitrary caller.
+func (client *clientType) Kick() {
+	go func() { hub.clientDel <- client }()
 }
 
 func (hub *hubType) start() {
@@ -119,6 +174,7 @@ func (hub *hubType) start() {
 			client.ws.WriteMessage(websocket.CloseMessage, []byte{}) // optional
 			client.ws.Close()
 			client.ctxCancel()
+			broker.UnsubscribeAll(client)
 			delete(hub.clients, client)
 			cluster.SetWebsocketClientCount(len(hub.clients))
 		}
@@ -134,78 +190,108 @@ func (hub *hubType) start() {
 		case client := <-hub.clientDel:
 			removeClient(client)
 
+		case opEvent := <-operations.Events:
+			jsonMsg, err := prepareUnrequested("operation_event", opEvent)
+			if err != nil {
+				log.Error(handlerContext, "could not prepare operation event", err)
+				continue
+			}
+			for client := range hub.clients {
+				// operations are personal, only the owning login is informed
+				// (admins use operation_list to poll others on demand)
+				if client.loginId == opEvent.LoginId {
+					client.write(jsonMsg)
+				}
+			}
+
 		case event := <-cluster.WebsocketClientEvents:
 
 			jsonMsg := []byte{} // message back to client
 			kickEvent := event.Kick || event.KickNonAdmin
 
-			if !kickEvent {
-				// if clients are not kicked, prepare response
-				var err error
+			if kickEvent {
+				// kicks go through the broker's always-delivered KickTopic
+				// instead of walking hub.clients directly
+				log.Info(handlerContext, "kicking clients")
+				broker.Kick(event.KickNonAdmin)
+				continue
+			}
 
-				if event.CollectionChanged != uuid.Nil {
-					jsonMsg, err = prepareUnrequested("collection_changed", event.CollectionChanged)
-				}
-				if event.ConfigChanged {
-					jsonMsg, err = prepareUnrequested("config_changed", nil)
-				}
-				if event.FilesCopiedAttributeId != uuid.Nil {
-					jsonMsg, err = prepareUnrequested("files_copied", types.ClusterEventFilesCopied{
-						AttributeId: event.FilesCopiedAttributeId,
-						FileIds:     event.FilesCopiedFileIds,
-						RecordId:    event.FilesCopiedRecordId,
-					})
-				}
-				if event.FileRequestedAttributeId != uuid.Nil {
-					jsonMsg, err = prepareUnrequested("fileRequested", types.ClusterEventFileRequested{
-						AttributeId: event.FileRequestedAttributeId,
-						ChooseApp:   event.FileRequestedChooseApp,
-						FileId:      event.FileRequestedFileId,
-						FileHash:    event.FileRequestedFileHash,
-						FileName:    event.FileRequestedFileName,
-					})
-				}
-				if event.Renew {
-					jsonMsg, err = prepareUnrequested("reauthorized", nil)
-				}
-				if event.SchemaLoading {
-					jsonMsg, err = prepareUnrequested("schema_loading", nil)
-				}
-				if event.SchemaLoaded {
-					data := struct {
-						ModuleIdMapData     map[uuid.UUID]types.ModuleMeta `json:"moduleIdMapData"`
-						PresetIdMapRecordId map[uuid.UUID]int64            `json:"presetIdMapRecordId"`
-					}{
-						ModuleIdMapData:     cache.GetModuleIdMapMeta(),
-						PresetIdMapRecordId: cache.GetPresetRecordIds(),
-					}
-					jsonMsg, err = prepareUnrequested("schema_loaded", data)
-				}
+			if event.CollectionChanged != uuid.Nil {
+				// collection_changed is typically only relevant to the
+				// handful of clients with that collection's form open, so it
+				// goes through the topic broker instead of walking every
+				// connected client
+				jsonMsg, err := prepareUnrequested("collection_changed", event.CollectionChanged)
 				if err != nil {
 					log.Error(handlerContext, "could not prepare unrequested transaction", err)
 					continue
 				}
+
+				if event.LoginId == 0 {
+					// login ID 0 affects all subscribers of the collection
+					broker.Publish(collectionTopic(event.CollectionChanged), jsonMsg)
+				} else {
+					// scoped to one login, same semantics as the loop below -
+					// only that login's subscribed clients are informed
+					broker.PublishFiltered(collectionTopic(event.CollectionChanged), jsonMsg,
+						func(c broker.Client) bool { return c.LoginId() == event.LoginId })
+				}
+				continue
+			}
+
+			var err error
+
+			if event.ConfigChanged {
+				jsonMsg, err = prepareUnrequested("config_changed", nil)
+			}
+			if event.FilesCopiedAttributeId != uuid.Nil {
+				jsonMsg, err = prepareUnrequested("files_copied", types.ClusterEventFilesCopied{
+					AttributeId: event.FilesCopiedAttributeId,
+					FileIds:     event.FilesCopiedFileIds,
+					RecordId:    event.FilesCopiedRecordId,
+				})
+			}
+			if event.FileRequestedAttributeId != uuid.Nil {
+				jsonMsg, err = prepareUnrequested("fileRequested", types.ClusterEventFileRequested{
+					AttributeId: event.FileRequestedAttributeId,
+					ChooseApp:   event.FileRequestedChooseApp,
+					FileId:      event.FileRequestedFileId,
+					FileHash:    event.FileRequestedFileHash,
+					FileName:    event.FileRequestedFileName,
+				})
+			}
+			if event.Renew {
+				jsonMsg, err = prepareUnrequested("reauthorized", nil)
+			}
+			if event.SchemaLoading {
+				jsonMsg, err = prepareUnrequested("schema_loading", nil)
+			}
+			if event.SchemaLoaded {
+				data := struct {
+					ModuleIdMapData     map[uuid.UUID]types.ModuleMeta `json:"moduleIdMapData"`
+					PresetIdMapRecordId map[uuid.UUID]int64            `json:"presetIdMapRecordId"`
+				}{
+					ModuleIdMapData:     cache.GetModuleIdMapMeta(),
+					PresetIdMapRecordId: cache.GetPresetRecordIds(),
+				}
+				jsonMsg, err = prepareUnrequested("schema_loaded", data)
+			}
+			if err != nil {
+				log.Error(handlerContext, "could not prepare unrequested transaction", err)
+				continue
 			}
 
-			for client, _ := range hub.clients {
+			for client := range hub.clients {
 
 				// login ID 0 affects all
 				if event.LoginId != 0 && event.LoginId != client.loginId {
 					continue
 				}
 
-				// non-kick event, send message
-				if !kickEvent {
-					go client.write(jsonMsg)
-				}
-
-				// kick client, if requested
-				if event.Kick || (event.KickNonAdmin && !client.admin) {
-					log.Info(handlerContext, fmt.Sprintf("kicking client (login ID %d)",
-						client.loginId))
-
-					removeClient(client)
-				}
+				// a non-blocking send means one slow client can no longer
+				// stall fanout to everyone else
+				client.write(jsonMsg)
 			}
 		}
 	}
@@ -226,22 +312,67 @@ func (client *clientType) read() {
 	}
 }
 
+// write queues message for delivery by the client's writer goroutine. It
+// never blocks: if the queue is full the client is considered too slow to
+// keep up and is dropped, instead of piling up pending writes or goroutines.
+// write is called both from client goroutines and, for broadcasts, directly
+// from hub.start's own goroutine - so the full-queue path must never block
+// on anything hub.start itself reads, or a single stalled client would
+// freeze the whole hub.
 func (client *clientType) write(message []byte) {
-	client.write_mx.Lock()
-	defer client.write_mx.Unlock()
+	select {
+	case client.send <- message:
+	default:
+		log.Warning(handlerContext, "dropped client",
+			fmt.Errorf("send queue full for login ID %d (%s)", client.loginId, client.address))
+
+		// hub.start's own goroutine is sometimes the caller of write() (its
+		// broadcast paths), and hub.clientDel is only ever drained by that
+		// same goroutine - sending here directly would deadlock it. The close
+		// frame (which can wait up to writeWait on a stalled connection) and
+		// the delete are delivered from a fresh goroutine instead so they can
+		// block freely without blocking the hub.
+		go func() {
+			client.ws.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(1013, "Try Again Later"),
+				time.Now().Add(writeWait))
 
-	if err := client.ws.WriteMessage(websocket.TextMessage, message); err != nil {
-		hub.clientDel <- client
-		return
+			hub.clientDel <- client
+		}()
 	}
 }
 
-func (client *clientType) handleTransaction(reqTransJson json.RawMessage) json.RawMessage {
-	hubRequestLimit <- true
-	defer func() {
-		<-hubRequestLimit
-	}()
+// writePump is the single goroutine allowed to write to the client's
+// websocket connection: it drains the send queue, sets write deadlines, and
+// sends periodic pings so a dead TCP connection is noticed even without
+// application traffic.
+func (client *clientType) writePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case message := <-client.send:
+			client.ws.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := client.ws.WriteMessage(websocket.TextMessage, message); err != nil {
+				hub.clientDel <- client
+				return
+			}
 
+		case <-ticker.C:
+			client.ws.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := client.ws.WriteMessage(websocket.PingMessage, nil); err != nil {
+				hub.clientDel <- client
+				return
+			}
+
+		case <-client.ctx.Done():
+			return
+		}
+	}
+}
+
+func (client *clientType) handleTransaction(reqTransJson json.RawMessage) json.RawMessage {
 	var (
 		reqTrans types.RequestTransaction
 		resTrans types.ResponseTransaction
@@ -259,82 +390,362 @@ func (client *clientType) handleTransaction(reqTransJson json.RawMessage) json.R
 	// take over transaction number for response so client can match it locally
 	resTrans.TransactionNr = reqTrans.TransactionNr
 
-	// client can either authenticate or execute requests
+	// client can either authenticate, manage an operation, manage a topic
+	// subscription or execute requests
 	authRequest := len(reqTrans.Requests) == 1 && reqTrans.Requests[0].Ressource == "auth"
+	opRequest := len(reqTrans.Requests) == 1 && isOperationRessource(reqTrans.Requests[0].Ressource)
+	subRequest := len(reqTrans.Requests) == 1 && isSubscriptionRessource(reqTrans.Requests[0].Ressource)
+	settingSetRequest := len(reqTrans.Requests) == 1 && reqTrans.Requests[0].Ressource == "setting_set"
 
-	if !authRequest {
+	if opRequest {
+		// operation lookups are cheap registry reads, they bypass the
+		// scheduler entirely so they never queue behind a busy login
 		if client.fixedToken {
 			log.Warning(handlerContext, "blocked client request",
 				fmt.Errorf("only authentication allowed for fixed token clients"))
 
 			return []byte("{}")
 		}
+		resTrans = client.handleOperationRequest(reqTrans.Requests[0], resTrans)
 
-		// execute non-authentication transaction
-		resTrans = request.ExecTransaction(client.ctx, client.loginId,
-			client.admin, client.noAuth, reqTrans, resTrans)
+	} else if subRequest {
+		if client.fixedToken {
+			log.Warning(handlerContext, "blocked client request",
+				fmt.Errorf("only authentication allowed for fixed token clients"))
+
+			return []byte("{}")
+		}
+		resTrans = client.handleSubscriptionRequest(reqTrans.Requests[0], resTrans)
+
+	} else if client.fixedToken && !authRequest {
+		log.Warning(handlerContext, "blocked client request",
+			fmt.Errorf("only authentication allowed for fixed token clients"))
+
+		return []byte("{}")
+
+	} else if reqTrans.Async {
+		// caller asked for the transaction to run as a tracked background
+		// operation instead of blocking this request for its full duration -
+		// the operation owns the scheduler submission, the response here
+		// only carries the operation ID/status URL to poll or subscribe to
+		resTrans = client.handleAsyncTransaction(reqTrans, resTrans)
 
 	} else {
-		// execute authentication request
-		var req = reqTrans.Requests[0]
-		resTrans.Responses = make([]types.Response, 0)
+		// fixed-token/auth traffic gets its own high-priority class so it
+		// can never be blocked behind regular transactions of busy logins
+		class := scheduler.ClassDefault
+		if authRequest {
+			class = scheduler.ClassAuth
+		}
 
-		if blocked := bruteforce.CheckByHost(client.address); blocked {
-			hub.clientDel <- client
+		err := scheduler.Submit(client.ctx, client.loginId, class, func() {
+			switch {
+			case authRequest:
+				resTrans = client.handleAuthRequest(reqTrans.Requests[0], resTrans)
+			case settingSetRequest:
+				// handled directly rather than via request.ExecTransaction,
+				// because setting.Set_tx's conflict result (and the fresh
+				// fingerprint on success) needs to reach the client as-is,
+				// not folded into a generic transaction response
+				resTrans = client.handleSettingSetRequest(reqTrans.Requests[0], resTrans)
+			default:
+				resTrans = request.ExecTransaction(client.ctx, client.loginId,
+					client.admin, client.noAuth, reqTrans, resTrans)
+			}
+		})
+		if err != nil {
+			// client disconnected or was rejected while queued
 			return []byte("{}")
 		}
+	}
 
-		var err error
-		var resPayload interface{}
+	// marshal response transaction
+	resTransJson, err := json.Marshal(resTrans)
+	if err != nil {
+		log.Error(handlerContext, "cannot marshal responses", err)
+		return []byte("{}")
+	}
+	return resTransJson
+}
 
-		switch req.Action {
-		case "token": // authentication via JSON web token
-			resPayload, err = request.LoginAuthToken(req.Payload,
-				&client.loginId, &client.admin, &client.noAuth)
+// handleAuthRequest executes the single "auth" request a (not yet
+// authenticated) client may send.
+func (client *clientType) handleAuthRequest(req types.Request, resTrans types.ResponseTransaction) types.ResponseTransaction {
+	resTrans.Responses = make([]types.Response, 0)
 
-		case "tokenFixed": // authentication via fixed token (fat-client)
-			resPayload, err = request.LoginAuthTokenFixed(req.Payload,
-				&client.loginId, &client.fixedToken)
+	if blocked := bruteforce.CheckByHost(client.address); blocked {
+		hub.clientDel <- client
+		return resTrans
+	}
 
-		case "user": // authentication via credentials
-			resPayload, err = request.LoginAuthUser(req.Payload,
-				&client.loginId, &client.admin, &client.noAuth)
-		}
+	var err error
+	var resPayload interface{}
+
+	switch req.Action {
+	case "token": // authentication via JSON web token
+		resPayload, err = request.LoginAuthToken(req.Payload,
+			&client.loginId, &client.admin, &client.noAuth)
+
+	case "tokenFixed": // authentication via fixed token (fat-client)
+		resPayload, err = request.LoginAuthTokenFixed(req.Payload,
+			&client.loginId, &client.fixedToken)
+
+	case "user": // authentication via credentials
+		resPayload, err = request.LoginAuthUser(req.Payload,
+			&client.loginId, &client.admin, &client.noAuth)
+	}
 
+	if err != nil {
+		log.Warning(handlerContext, "failed to authenticate user", err)
+		bruteforce.BadAttemptByHost(client.address)
+
+		if handler.CheckForLicenseErrCode(err) {
+			// license errors are relevant to the client
+			resTrans.Error = err.Error()
+		} else {
+			// any other error is not relevant to the client and could reveal internals
+			resTrans.Error = "AUTH_ERROR"
+		}
+	} else {
+		var res types.Response
+		res.Payload, err = json.Marshal(resPayload)
 		if err != nil {
-			log.Warning(handlerContext, "failed to authenticate user", err)
-			bruteforce.BadAttemptByHost(client.address)
-
-			if handler.CheckForLicenseErrCode(err) {
-				// license errors are relevant to the client
-				resTrans.Error = err.Error()
-			} else {
-				// any other error is not relevant to the client and could reveal internals
-				resTrans.Error = "AUTH_ERROR"
-			}
+			resTrans.Error = handler.ErrGeneral
 		} else {
-			var res types.Response
-			res.Payload, err = json.Marshal(resPayload)
+			resTrans.Responses = append(resTrans.Responses, res)
+		}
+	}
+
+	if resTrans.Error == "" {
+		log.Info(handlerContext, fmt.Sprintf("authenticated client (login ID %d, admin: %v)",
+			client.loginId, client.admin))
+	}
+	return resTrans
+}
+
+// handleAsyncTransaction runs reqTrans as a tracked background operation
+// instead of inline on the scheduler: it returns as soon as the operation is
+// registered, carrying the operation's ID/status URL, while the actual
+// request.ExecTransaction call (still scheduled under the client's regular
+// class, so it is not exempt from WFQ fairness) runs on the operation
+// worker pool and reports its result via operations.Events/SetResult.
+func (client *clientType) handleAsyncTransaction(reqTrans types.RequestTransaction, resTrans types.ResponseTransaction) types.ResponseTransaction {
+
+	op, err := operations.Start(client.ctx, client.loginId, func(op *operations.Operation) error {
+		var execErr error
+		err := scheduler.Submit(op.Ctx, client.loginId, scheduler.ClassDefault, func() {
+			asyncResTrans := request.ExecTransaction(op.Ctx, client.loginId,
+				client.admin, client.noAuth, reqTrans, types.ResponseTransaction{
+					TransactionNr: reqTrans.TransactionNr,
+				})
+
+			if asyncResTrans.Error != "" {
+				execErr = fmt.Errorf("%s", asyncResTrans.Error)
+				return
+			}
+
+			resultJson, err := json.Marshal(asyncResTrans.Responses)
 			if err != nil {
-				resTrans.Error = handler.ErrGeneral
-			} else {
-				resTrans.Responses = append(resTrans.Responses, res)
+				execErr = err
+				return
 			}
+			op.SetResult(resultJson)
+		})
+		if err != nil {
+			return err
 		}
+		return execErr
+	})
 
-		if resTrans.Error == "" {
-			log.Info(handlerContext, fmt.Sprintf("authenticated client (login ID %d, admin: %v)",
-				client.loginId, client.admin))
+	if err != nil {
+		log.Error(handlerContext, "failed to start async transaction", err)
+		resTrans.Error = handler.ErrGeneral
+		return resTrans
+	}
+
+	payloadJson, err := json.Marshal(op)
+	if err != nil {
+		resTrans.Error = handler.ErrGeneral
+		return resTrans
+	}
+
+	resTrans.Responses = append(resTrans.Responses, types.Response{
+		Payload: payloadJson,
+	})
+	return resTrans
+}
+
+// handleSettingSetRequest persists the client's settings via setting.Set_tx,
+// mapping ErrSettingsConflict to a client-visible error code so the UI can
+// surface a "reload and retry" dialog instead of the write being silently
+// lost or silently clobbering a concurrent change.
+func (client *clientType) handleSettingSetRequest(req types.Request, resTrans types.ResponseTransaction) types.ResponseTransaction {
+	var s types.Settings
+	if err := json.Unmarshal(req.Payload, &s); err != nil {
+		resTrans.Error = handler.ErrGeneral
+		return resTrans
+	}
+
+	tx, err := db.Pool.Begin(db.Ctx)
+	if err != nil {
+		log.Error(handlerContext, "failed to start transaction for setting_set", err)
+		resTrans.Error = handler.ErrGeneral
+		return resTrans
+	}
+
+	fingerprint, err := setting.Set_tx(tx, client.loginId, s)
+	if err != nil {
+		tx.Rollback(db.Ctx)
+
+		if errors.Is(err, setting.ErrSettingsConflict) {
+			resTrans.Error = "SETTINGS_CONFLICT"
+		} else {
+			log.Error(handlerContext, "failed to persist settings", err)
+			resTrans.Error = handler.ErrGeneral
 		}
+		return resTrans
 	}
 
-	// marshal response transaction
-	resTransJson, err := json.Marshal(resTrans)
+	if err := tx.Commit(db.Ctx); err != nil {
+		log.Error(handlerContext, "failed to commit settings update", err)
+		resTrans.Error = handler.ErrGeneral
+		return resTrans
+	}
+
+	payloadJson, err := json.Marshal(struct {
+		Fingerprint string `json:"fingerprint"`
+	}{Fingerprint: fingerprint})
 	if err != nil {
-		log.Error(handlerContext, "cannot marshal responses", err)
-		return []byte("{}")
+		resTrans.Error = handler.ErrGeneral
+		return resTrans
 	}
-	return resTransJson
+
+	resTrans.Responses = append(resTrans.Responses, types.Response{
+		Payload: payloadJson,
+	})
+	return resTrans
+}
+
+func isOperationRessource(ressource string) bool {
+	return strings.HasPrefix(ressource, "operation_")
+}
+
+func isSubscriptionRessource(ressource string) bool {
+	return ressource == "subscribe" || ressource == "unsubscribe"
+}
+
+// collectionTopic is the broker topic a client joins to be informed about
+// changes to one specific collection.
+func collectionTopic(collectionId uuid.UUID) string {
+	return fmt.Sprintf("collection:%s", collectionId)
+}
+
+// handleSubscriptionRequest serves the subscribe/unsubscribe resources, by
+// which a client registers or drops interest in a broker topic. Currently
+// only "collection:{uuid}" (see collectionTopic) is published to; arbitrary
+// other topic strings can be subscribed to but will never receive anything
+// until a publisher for them exists.
+func (client *clientType) handleSubscriptionRequest(req types.Request, resTrans types.ResponseTransaction) types.ResponseTransaction {
+	var payload struct {
+		Topic string `json:"topic"`
+	}
+	if err := json.Unmarshal(req.Payload, &payload); err != nil {
+		resTrans.Error = handler.ErrGeneral
+		return resTrans
+	}
+
+	if req.Ressource == "subscribe" {
+		broker.Subscribe(payload.Topic, client)
+	} else {
+		broker.Unsubscribe(payload.Topic, client)
+	}
+
+	resTrans.Responses = append(resTrans.Responses, types.Response{
+		Payload: json.RawMessage("{}"),
+	})
+	return resTrans
+}
+
+// handleOperationRequest serves the operation_get/operation_cancel/
+// operation_subscribe/operation_list resources directly, bypassing both
+// request.ExecTransaction and the scheduler so operation lookups never
+// queue behind a busy login.
+func (client *clientType) handleOperationRequest(req types.Request, resTrans types.ResponseTransaction) types.ResponseTransaction {
+
+	var resPayload interface{}
+	var err error
+
+	switch req.Ressource {
+	case "operation_get":
+		var payload struct {
+			Id uuid.UUID `json:"id"`
+		}
+		if err = json.Unmarshal(req.Payload, &payload); err == nil {
+			resPayload, err = operations.Get(payload.Id, client.loginId, client.admin)
+		}
+
+	case "operation_cancel":
+		var payload struct {
+			Id uuid.UUID `json:"id"`
+		}
+		if err = json.Unmarshal(req.Payload, &payload); err == nil {
+			err = operations.Cancel(payload.Id, client.loginId, client.admin)
+		}
+
+	case "operation_subscribe":
+		// subscription is implicit: any client connected with a matching
+		// login ID already receives operation_event messages, this request
+		// only confirms the operation exists and is visible to the client
+		var payload struct {
+			Id uuid.UUID `json:"id"`
+		}
+		if err = json.Unmarshal(req.Payload, &payload); err == nil {
+			resPayload, err = operations.Get(payload.Id, client.loginId, client.admin)
+		}
+
+	case "operation_list":
+		if !client.admin {
+			err = fmt.Errorf("operation_list requires admin access")
+		} else {
+			resPayload = operations.List(client.loginId, client.admin)
+		}
+
+	case "operation_migrate_filestore":
+		// admin-triggered, one-off move of files still sitting on local disk
+		// to the currently configured storage driver (e.g. after switching
+		// Storage.Driver from "local" to "s3"); run as a background
+		// operation since a full instance's files can take a while
+		var payload struct {
+			LocalPathPrefix string `json:"localPathPrefix"`
+		}
+		if !client.admin {
+			err = fmt.Errorf("operation_migrate_filestore requires admin access")
+		} else if err = json.Unmarshal(req.Payload, &payload); err == nil {
+			resPayload, err = operations.Start(client.ctx, client.loginId, func(op *operations.Operation) error {
+				return filestore.MigrateLocalToActive(op.Ctx, payload.LocalPathPrefix)
+			})
+		}
+
+	default:
+		err = fmt.Errorf("unknown operation ressource '%s'", req.Ressource)
+	}
+
+	if err != nil {
+		log.Warning(handlerContext, "failed to handle operation request", err)
+		resTrans.Error = err.Error()
+		return resTrans
+	}
+
+	payloadJson, err := json.Marshal(resPayload)
+	if err != nil {
+		resTrans.Error = handler.ErrGeneral
+		return resTrans
+	}
+
+	resTrans.Responses = append(resTrans.Responses, types.Response{
+		Payload: payloadJson,
+	})
+	return resTrans
 }
 
 func prepareUnrequested(ressource string, payload interface{}) ([]byte, error) {
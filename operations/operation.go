@@ -0,0 +1,267 @@
+// Package operations implements long-running, cancellable background work that
+// would otherwise block a websocket request slot for its full duration (CSV
+// imports, file copies, report queries, ...). The split mirrors LXD's
+// operations/events model: a request starts an operation and gets back an ID
+// immediately, the operation runs on a bounded worker pool, and state
+// transitions plus progress are pushed to subscribed clients as unrequested
+// websocket events.
+package operations
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"r3/log"
+	"sync"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+type status string
+
+const (
+	StatusPending   status = "PENDING"
+	StatusRunning   status = "RUNNING"
+	StatusDone      status = "DONE"
+	StatusCancelled status = "CANCELLED"
+	StatusErrored   status = "ERRORED"
+
+	handlerContext = "operations"
+
+	// worker pool size, independent from the websocket request slot limit
+	workerLimit = 20
+
+	// statusURLFormat builds the StatusURL reported with each operation.
+	// Neither this package nor the websocket handler serves this path over
+	// HTTP; an operation's state and result are read via operation_get.
+	statusURLFormat = "/api/operation/%s"
+
+	// retention is how long a finished operation (DONE/CANCELLED/ERRORED)
+	// stays in registry before being evicted, giving clients a window to
+	// poll or subscribe for the final state without the map growing
+	// unbounded over the life of the process.
+	retention = 10 * time.Minute
+)
+
+// Work is the function executed by the worker pool. It receives the
+// operation so it can report progress and observe cancellation via Ctx.
+type Work func(op *Operation) error
+
+// Operation is a single tracked unit of background work.
+type Operation struct {
+	Id        uuid.UUID
+	LoginId   int64
+	Status    status
+	Progress  int // 0-100
+	Message   string
+	Error     string
+	StatusURL string
+	Result    json.RawMessage // set once Status reaches StatusDone
+
+	// Ctx is untagged for JSON because *Operation is marshalled directly as
+	// a websocket response payload (operation_get/_subscribe/_list, and the
+	// immediate response to an async transaction) - walking the live
+	// context chain into that response would be noise at best, an internal
+	// state leak at worst.
+	Ctx       context.Context `json:"-"`
+	ctxCancel context.CancelFunc
+	mx        sync.Mutex
+}
+
+// Event is posted to Events whenever an operation changes state or progress,
+// so the websocket hub can fan it out as an unrequested "operation_event".
+type Event struct {
+	LoginId     int64
+	OperationId uuid.UUID
+	Status      status
+	Progress    int
+	Message     string
+	Error       string
+}
+
+var (
+	Events = make(chan Event, 256)
+
+	registry   = make(map[uuid.UUID]*Operation)
+	registryMx sync.RWMutex
+
+	jobs = make(chan func(), workerLimit)
+)
+
+// StartBackgroundTasks launches the fixed-size worker pool. Operations queued
+// while all workers are busy wait in the jobs channel (capacity workerLimit)
+// rather than spawning unbounded goroutines; once that channel is full,
+// Start blocks until a slot frees up.
+func StartBackgroundTasks() {
+	for i := 0; i < workerLimit; i++ {
+		go worker()
+	}
+}
+
+func worker() {
+	for job := range jobs {
+		job()
+	}
+}
+
+// Start registers a new operation for loginId and schedules work on the
+// worker pool. ctx is the client's connection context, so the operation is
+// auto-cancelled if the client disconnects. Start returns as soon as the work
+// is queued (it blocks only while the jobs channel is full); the caller gets
+// the operation ID back to poll or subscribe to.
+func Start(ctx context.Context, loginId int64, work Work) (*Operation, error) {
+	id, err := uuid.NewV4()
+	if err != nil {
+		return nil, err
+	}
+
+	opCtx, cancel := context.WithCancel(ctx)
+	op := &Operation{
+		Id:        id,
+		LoginId:   loginId,
+		Status:    StatusPending,
+		StatusURL: fmt.Sprintf(statusURLFormat, id),
+		Ctx:       opCtx,
+		ctxCancel: cancel,
+	}
+
+	registryMx.Lock()
+	registry[id] = op
+	registryMx.Unlock()
+
+	emit(op)
+
+	jobs <- func() {
+		op.setStatus(StatusRunning, 0, "")
+
+		// auto-cancel is wired through opCtx; workers are expected to check
+		// op.Ctx.Err() periodically for long-running loops
+		err := work(op)
+
+		select {
+		case <-opCtx.Done():
+			op.setStatus(StatusCancelled, op.Progress, "")
+			return
+		default:
+		}
+
+		if err != nil {
+			op.mx.Lock()
+			op.Error = err.Error()
+			op.mx.Unlock()
+			op.setStatus(StatusErrored, op.Progress, "")
+			log.Error(handlerContext, fmt.Sprintf("operation %s failed", id), err)
+			return
+		}
+		op.setStatus(StatusDone, 100, "")
+	}
+	return op, nil
+}
+
+// Progress is called by worker code to report incremental progress. It is a
+// no-op if the operation is unknown (e.g. already cleaned up).
+func Progress(id uuid.UUID, pct int, msg string) {
+	registryMx.RLock()
+	op, exists := registry[id]
+	registryMx.RUnlock()
+	if !exists {
+		return
+	}
+	op.setStatus(StatusRunning, pct, msg)
+}
+
+// SetResult attaches the work result to be returned to clients that call
+// operation_get or operation_subscribe, also after the operation has already
+// finished. It is
+// called by Work implementations before returning, and is a no-op if not
+// called (Result then stays nil).
+func (op *Operation) SetResult(result json.RawMessage) {
+	op.mx.Lock()
+	op.Result = result
+	op.mx.Unlock()
+}
+
+func (op *Operation) setStatus(s status, pct int, msg string) {
+	op.mx.Lock()
+	op.Status = s
+	op.Progress = pct
+	if msg != "" {
+		op.Message = msg
+	}
+	op.mx.Unlock()
+	emit(op)
+
+	if isTerminal(s) {
+		time.AfterFunc(retention, func() {
+			registryMx.Lock()
+			delete(registry, op.Id)
+			registryMx.Unlock()
+		})
+	}
+}
+
+func isTerminal(s status) bool {
+	return s == StatusDone || s == StatusCancelled || s == StatusErrored
+}
+
+func emit(op *Operation) {
+	op.mx.Lock()
+	ev := Event{
+		LoginId:     op.LoginId,
+		OperationId: op.Id,
+		Status:      op.Status,
+		Progress:    op.Progress,
+		Message:     op.Message,
+		Error:       op.Error,
+	}
+	op.mx.Unlock()
+
+	select {
+	case Events <- ev:
+	default:
+		// events channel is full, drop rather than block the worker
+		log.Warning(handlerContext, "dropped operation event", fmt.Errorf("events channel full"))
+	}
+}
+
+// Get returns the operation by ID. Non-admin callers may only see their own.
+func Get(id uuid.UUID, loginId int64, admin bool) (*Operation, error) {
+	registryMx.RLock()
+	op, exists := registry[id]
+	registryMx.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("operation '%s' does not exist", id)
+	}
+	if !admin && op.LoginId != loginId {
+		return nil, fmt.Errorf("operation '%s' does not exist", id)
+	}
+	return op, nil
+}
+
+// Cancel requests cancellation of a running operation owned by loginId (or
+// any operation, for admins).
+func Cancel(id uuid.UUID, loginId int64, admin bool) error {
+	op, err := Get(id, loginId, admin)
+	if err != nil {
+		return err
+	}
+	op.ctxCancel()
+	return nil
+}
+
+// List returns all operations visible to loginId: its own, or all of them
+// for admins.
+func List(loginId int64, admin bool) []*Operation {
+	registryMx.RLock()
+	defer registryMx.RUnlock()
+
+	ops := make([]*Operation, 0, len(registry))
+	for _, op := range registry {
+		if admin || op.LoginId == loginId {
+			ops = append(ops, op)
+		}
+	}
+	return ops
+}
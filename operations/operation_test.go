@@ -0,0 +1,116 @@
+package operations
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+func TestStartRunsWorkAndReportsResult(t *testing.T) {
+	go worker() // single worker is enough to drain one job
+
+	op, err := Start(context.Background(), 1, func(op *Operation) error {
+		op.SetResult([]byte(`{"ok":true}`))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Start returned error: %v", err)
+	}
+	if op.StatusURL == "" {
+		t.Fatal("expected StatusURL to be set")
+	}
+
+	waitForStatus(t, op.Id, StatusDone)
+
+	got, err := Get(op.Id, 1, false)
+	if err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+	if string(got.Result) != `{"ok":true}` {
+		t.Fatalf("expected result to be preserved, got %q", got.Result)
+	}
+}
+
+func TestStartReportsErrors(t *testing.T) {
+	go worker()
+
+	wantErr := errors.New("boom")
+	op, err := Start(context.Background(), 1, func(op *Operation) error {
+		return wantErr
+	})
+	if err != nil {
+		t.Fatalf("Start returned error: %v", err)
+	}
+
+	waitForStatus(t, op.Id, StatusErrored)
+
+	got, err := Get(op.Id, 1, false)
+	if err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+	if got.Error != wantErr.Error() {
+		t.Fatalf("expected error %q, got %q", wantErr.Error(), got.Error)
+	}
+}
+
+func TestGetHidesOtherLoginsOperations(t *testing.T) {
+	go worker()
+
+	op, err := Start(context.Background(), 1, func(op *Operation) error { return nil })
+	if err != nil {
+		t.Fatalf("Start returned error: %v", err)
+	}
+	waitForStatus(t, op.Id, StatusDone)
+
+	if _, err := Get(op.Id, 2, false); err == nil {
+		t.Fatal("expected non-owning, non-admin login to be refused")
+	}
+	if _, err := Get(op.Id, 2, true); err != nil {
+		t.Fatalf("expected admin to see any operation, got error: %v", err)
+	}
+}
+
+func TestCancelStopsOperationContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	go worker()
+	op, err := Start(ctx, 1, func(op *Operation) error {
+		close(started)
+		<-op.Ctx.Done()
+		<-release
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Start returned error: %v", err)
+	}
+
+	<-started
+	if err := Cancel(op.Id, 1, false); err != nil {
+		t.Fatalf("Cancel returned error: %v", err)
+	}
+	close(release)
+
+	waitForStatus(t, op.Id, StatusCancelled)
+}
+
+func waitForStatus(t *testing.T, id uuid.UUID, want status) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		registryMx.RLock()
+		op, exists := registry[id]
+		registryMx.RUnlock()
+		if exists && op.Status == want {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for status %q", want)
+}
@@ -0,0 +1,230 @@
+// Package scheduler replaces the flat 10-slot global semaphore that used to
+// gate every websocket transaction. It implements weighted fair queuing
+// (WFQ): each (loginId, Class) pair gets its own FIFO, and a shared pool of
+// workers always dispatches whichever queued job has the lowest virtual
+// finish time, so one busy login can no longer starve the rest.
+package scheduler
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"r3/log"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Class separates fixed-token/auth traffic (which must never be blocked by
+// regular transactions) from everything else.
+type Class int
+
+const (
+	ClassDefault Class = iota
+	ClassAuth
+
+	handlerContext = "scheduler"
+
+	// maximum default-class jobs a single login may have queued at once.
+	perLoginQueueCap = 100
+
+	// maximum auth-class jobs queued at once. Almost all auth traffic runs
+	// with loginId 0 (pre-authentication), so perLoginQueueCap would really
+	// be a server-wide cap on simultaneous logins rather than a per-user
+	// one; authQueueCap is its own, much larger bound instead, so a burst
+	// of legitimate concurrent logins isn't spuriously rejected while still
+	// closing off unbounded growth of that one flow.
+	authQueueCap = 2000
+)
+
+// Job is one unit of scheduled work. run executes the actual transaction and
+// must respect ctx (it is the originating client's connection context).
+type Job struct {
+	ctx     context.Context
+	loginId int64
+	class   Class
+	run     func()
+
+	enqueuedAt time.Time
+	vFinish    float64 // virtual finish time, lower runs first
+	index      int     // heap index, maintained by container/heap
+}
+
+// flowKey identifies one WFQ flow: a login's jobs of one class. Keeping
+// vTime/queueDepth per (loginId, class) rather than per loginId alone means
+// a login's heavy default-class traffic cannot inflate the virtual clock its
+// own auth-class jobs start from.
+type flowKey struct {
+	loginId int64
+	class   Class
+}
+
+var (
+	workerCount = runtime.NumCPU() * 2
+
+	mx         sync.Mutex
+	cond       = sync.NewCond(&mx)
+	pq         = make(jobHeap, 0)
+	queueDepth = make(map[flowKey]int)     // flow -> queued job count
+	vTime      = make(map[flowKey]float64) // flow -> last virtual finish time, for WFQ fairness
+
+	metricQueueDepth = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "r3",
+		Subsystem: "scheduler",
+		Name:      "queue_depth",
+		Help:      "Number of transactions currently queued, per login",
+	}, []string{"login_id"})
+
+	metricWaitSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "r3",
+		Subsystem: "scheduler",
+		Name:      "wait_seconds",
+		Help:      "Time a transaction spent queued before it started running",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"class"})
+
+	metricRejections = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "r3",
+		Subsystem: "scheduler",
+		Name:      "rejections_total",
+		Help:      "Transactions rejected because a login exceeded its queue cap",
+	}, []string{"login_id"})
+)
+
+// jobHeap is a min-heap on vFinish, implementing container/heap.Interface.
+type jobHeap []*Job
+
+func (h jobHeap) Len() int           { return len(h) }
+func (h jobHeap) Less(i, j int) bool { return h[i].vFinish < h[j].vFinish }
+func (h jobHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i]; h[i].index = i; h[j].index = j }
+func (h *jobHeap) Push(x interface{}) {
+	job := x.(*Job)
+	job.index = len(*h)
+	*h = append(*h, job)
+}
+func (h *jobHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	job := old[n-1]
+	old[n-1] = nil
+	job.index = -1
+	*h = old[:n-1]
+	return job
+}
+
+// StartBackgroundTasks launches the fixed-size worker pool that drains the
+// priority queue.
+func StartBackgroundTasks() {
+	for i := 0; i < workerCount; i++ {
+		go worker()
+	}
+}
+
+func worker() {
+	for {
+		mx.Lock()
+		for pq.Len() == 0 {
+			cond.Wait()
+		}
+		job := heap.Pop(&pq).(*Job)
+		key := flowKey{loginId: job.loginId, class: job.class}
+		queueDepth[key]--
+		metricQueueDepth.WithLabelValues(fmt.Sprintf("%d", job.loginId)).Set(float64(
+			queueDepth[flowKey{loginId: job.loginId, class: ClassDefault}] +
+				queueDepth[flowKey{loginId: job.loginId, class: ClassAuth}]))
+		mx.Unlock()
+
+		metricWaitSeconds.WithLabelValues(classLabel(job.class)).Observe(
+			time.Since(job.enqueuedAt).Seconds())
+
+		if job.ctx.Err() == nil {
+			job.run()
+		}
+	}
+}
+
+func classLabel(c Class) string {
+	if c == ClassAuth {
+		return "auth"
+	}
+	return "default"
+}
+
+// weight gives auth-class jobs a much smaller vFinish increment, so they are
+// picked far sooner than regular transactions competing with heavy logins.
+func weight(c Class) float64 {
+	if c == ClassAuth {
+		return 0.01
+	}
+	return 1
+}
+
+// Submit enqueues fn to run on the worker pool under loginId/class and
+// blocks until it has run (or ctx is cancelled, in which case Submit returns
+// ctx.Err() at once — a job whose ctx is already cancelled when a worker
+// picks it up is skipped, while one that is already running keeps running).
+func Submit(ctx context.Context, loginId int64, class Class, fn func()) error {
+	key := flowKey{loginId: loginId, class: class}
+
+	queueCap := perLoginQueueCap
+	if class == ClassAuth {
+		queueCap = authQueueCap
+	}
+
+	mx.Lock()
+	if queueDepth[key] >= queueCap {
+		mx.Unlock()
+		metricRejections.WithLabelValues(fmt.Sprintf("%d", loginId)).Inc()
+		return fmt.Errorf("too many queued transactions for login ID %d", loginId)
+	}
+
+	done := make(chan struct{})
+	job := &Job{
+		ctx:        ctx,
+		loginId:    loginId,
+		class:      class,
+		enqueuedAt: time.Now(),
+	}
+	job.run = func() {
+		defer close(done)
+		fn()
+	}
+
+	// WFQ: a flow's next job starts no earlier than its last one finished,
+	// scaled by class weight, so heavy users get their fair share, not more -
+	// keyed per (loginId, class) so a login's heavy default-class backlog
+	// cannot inflate the start time of its own auth-class jobs
+	start := vTime[key]
+	if now := virtualNow(); now > start {
+		start = now
+	}
+	job.vFinish = start + weight(class)
+	vTime[key] = job.vFinish
+
+	queueDepth[key]++
+	metricQueueDepth.WithLabelValues(fmt.Sprintf("%d", loginId)).Set(float64(
+		queueDepth[flowKey{loginId: loginId, class: ClassDefault}] +
+			queueDepth[flowKey{loginId: loginId, class: ClassAuth}]))
+	heap.Push(&pq, job)
+	cond.Signal()
+	mx.Unlock()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		log.Info(handlerContext, fmt.Sprintf("dropped queued transaction for login ID %d on disconnect", loginId))
+		return ctx.Err()
+	}
+}
+
+// virtualNow is the virtual clock Submit starts a flow's next job from, so an
+// idle login cannot bank a head start over ones that have been busy. It keeps
+// no state: it is wall time in seconds rather than a count of jobs processed,
+// which is adequate for the fairness bound WFQ needs here.
+func virtualNow() float64 {
+	return float64(time.Now().UnixNano()) / float64(time.Second)
+}
@@ -0,0 +1,173 @@
+package scheduler
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWeightFavorsAuthClass(t *testing.T) {
+	if weight(ClassAuth) >= weight(ClassDefault) {
+		t.Fatalf("expected ClassAuth weight (%v) to be smaller than ClassDefault weight (%v)",
+			weight(ClassAuth), weight(ClassDefault))
+	}
+}
+
+// TestFlowKeyIsolatesClasses guards against vTime/queueDepth regressing to
+// being keyed by loginId alone: a login's default-class jobs must not
+// advance the virtual clock its own auth-class jobs start from.
+func TestFlowKeyIsolatesClasses(t *testing.T) {
+	defer resetState()
+
+	const loginId = int64(42)
+
+	mx.Lock()
+	vTime[flowKey{loginId: loginId, class: ClassDefault}] = 1_000_000
+	authStart := vTime[flowKey{loginId: loginId, class: ClassAuth}]
+	mx.Unlock()
+
+	if authStart != 0 {
+		t.Fatalf("expected auth-class virtual time for a fresh login to be 0, got %v", authStart)
+	}
+}
+
+func TestJobHeapOrdersByVFinish(t *testing.T) {
+	h := &jobHeap{}
+	heap.Init(h)
+	for _, vFinish := range []float64{3, 1, 2} {
+		heap.Push(h, &Job{vFinish: vFinish})
+	}
+
+	var got []float64
+	for h.Len() > 0 {
+		got = append(got, heap.Pop(h).(*Job).vFinish)
+	}
+
+	want := []float64{1, 2, 3}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected pop order %v, got %v", want, got)
+		}
+	}
+}
+
+// TestSubmitDispatchesByVirtualFinishOrder drives Submit/worker end-to-end:
+// three jobs are queued (without any worker draining yet) before a single
+// worker is started, so the order they actually run in is decided purely by
+// WFQ virtual finish time, not submission order - the auth-class job should
+// run first despite being submitted last.
+func TestSubmitDispatchesByVirtualFinishOrder(t *testing.T) {
+	defer resetState()
+
+	var mu sync.Mutex
+	var order []int64
+
+	var wg sync.WaitGroup
+	submit := func(loginId int64, class Class) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			Submit(context.Background(), loginId, class, func() {
+				mu.Lock()
+				order = append(order, loginId)
+				mu.Unlock()
+			})
+		}()
+	}
+
+	submit(1, ClassDefault)
+	submit(2, ClassDefault)
+	submit(3, ClassAuth) // much smaller weight - should be dispatched first
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		mx.Lock()
+		n := pq.Len()
+		mx.Unlock()
+		if n == 3 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for all three jobs to be queued")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	go worker()
+	wg.Wait()
+
+	if len(order) == 0 || order[0] != 3 {
+		t.Fatalf("expected auth-class job (login 3) to be dispatched first, got order %v", order)
+	}
+}
+
+// TestSubmitRejectsWhenPerLoginCapExceeded exercises the per-login rejection
+// path directly: a flow already sitting at its queue cap must be turned away
+// before a new job is ever pushed onto the heap.
+func TestSubmitRejectsWhenPerLoginCapExceeded(t *testing.T) {
+	defer resetState()
+
+	key := flowKey{loginId: 7, class: ClassDefault}
+	mx.Lock()
+	queueDepth[key] = perLoginQueueCap
+	mx.Unlock()
+
+	if err := Submit(context.Background(), 7, ClassDefault, func() {}); err == nil {
+		t.Fatal("expected Submit to reject a login already at its queue cap")
+	}
+}
+
+// TestSubmitGivesAuthClassItsOwnLargerCap confirms ClassAuth is judged
+// against authQueueCap rather than perLoginQueueCap: a flow sitting exactly
+// at perLoginQueueCap must still be accepted for ClassAuth. The ctx passed
+// in is already cancelled, so Submit is guaranteed to return via its
+// ctx.Done() branch rather than blocking on a worker that never runs here.
+func TestSubmitGivesAuthClassItsOwnLargerCap(t *testing.T) {
+	defer resetState()
+
+	key := flowKey{loginId: 0, class: ClassAuth}
+	mx.Lock()
+	queueDepth[key] = perLoginQueueCap
+	mx.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := Submit(ctx, 0, ClassAuth, func() {})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected a ClassAuth job at perLoginQueueCap to still be accepted (then dropped by the cancelled ctx), got %v", err)
+	}
+}
+
+// TestSubmitDropsJobWhenContextCancelledBeforeRun covers the other half of
+// worker()'s "if job.ctx.Err() == nil { job.run() }" check: if ctx is
+// already cancelled, Submit must return ctx.Err() without ever running fn,
+// whether or not a worker later drains the now-dead job off the queue.
+func TestSubmitDropsJobWhenContextCancelledBeforeRun(t *testing.T) {
+	defer resetState()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	ran := false
+	err := Submit(ctx, 999, ClassDefault, func() { ran = true })
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if ran {
+		t.Fatal("expected fn not to run once ctx was already cancelled before Submit returned")
+	}
+}
+
+// resetState clears global scheduler state between tests, since it is kept
+// at package scope rather than behind a constructor.
+func resetState() {
+	mx.Lock()
+	defer mx.Unlock()
+	queueDepth = make(map[flowKey]int)
+	vTime = make(map[flowKey]float64)
+	pq = make(jobHeap, 0)
+}
@@ -1,12 +1,21 @@
 package setting
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"r3/db"
 	"r3/types"
 
 	"github.com/jackc/pgx/v5"
 )
 
+// ErrSettingsConflict is returned by Set_tx if the row was changed by
+// another transaction (another browser tab, the fat client, ...) since the
+// caller last read it via Get.
+var ErrSettingsConflict = errors.New("settings have been changed since they were last read")
+
 func Get(loginId int64) (types.Settings, error) {
 	var s types.Settings
 
@@ -23,12 +32,42 @@ func Get(loginId int64) (types.Settings, error) {
 		&s.MobileScrollForm, &s.WarnUnsaved, &s.MenuColored, &s.Pattern,
 		&s.FontFamily, &s.TabRemember, &s.FieldClean)
 
+	if err != nil {
+		return s, err
+	}
+
+	s.Fingerprint, err = getFingerprint(s)
 	return s, err
 }
 
-func Set_tx(tx pgx.Tx, loginId int64, s types.Settings) error {
+// Set_tx persists s, but only if s.Fingerprint still matches the row as it
+// currently stands in the database - otherwise it aborts with
+// ErrSettingsConflict rather than silently overwriting a concurrent change.
+// getTx takes SELECT ... FOR UPDATE, so the row is locked for the remainder
+// of tx: a second, genuinely concurrent Set_tx for the same login blocks on
+// that lock instead of reading the same pre-commit fingerprint, and only
+// proceeds (with the fingerprint of whatever the first call just committed)
+// once the first caller's transaction has committed or rolled back. Without
+// this, two callers could both pass the comparison below and the second
+// UPDATE would silently clobber the first, without either ever observing
+// ErrSettingsConflict. On success Set_tx returns the fingerprint of the row
+// as written, for the caller to hand back to the client.
+func Set_tx(tx pgx.Tx, loginId int64, s types.Settings) (string, error) {
 
-	_, err := tx.Exec(db.Ctx, `
+	current, err := getTx(tx, loginId)
+	if err != nil {
+		return "", err
+	}
+
+	currentFingerprint, err := getFingerprint(current)
+	if err != nil {
+		return "", err
+	}
+	if currentFingerprint != s.Fingerprint {
+		return "", ErrSettingsConflict
+	}
+
+	if _, err := tx.Exec(db.Ctx, `
 		UPDATE instance.login_setting
 		SET language_code = $1, date_format = $2, sunday_first_dow = $3,
 			font_size = $4, borders_all = $5, borders_corner = $6,
@@ -41,9 +80,51 @@ func Set_tx(tx pgx.Tx, loginId int64, s types.Settings) error {
 		s.BordersCorner, s.PageLimit, s.HeaderCaptions, s.Spacing, s.Dark,
 		s.Compact, s.HintUpdateVersion, s.MobileScrollForm, s.WarnUnsaved,
 		s.MenuColored, s.Pattern, s.FontFamily, s.TabRemember, s.FieldClean,
-		loginId)
+		loginId); err != nil {
 
-	return err
+		return "", err
+	}
+
+	return getFingerprint(s)
+}
+
+// getTx reads the row as it currently stands inside tx, locking it for the
+// remainder of tx so Set_tx's fingerprint check and its UPDATE are atomic
+// with respect to another concurrent Set_tx on the same login.
+func getTx(tx pgx.Tx, loginId int64) (types.Settings, error) {
+	var s types.Settings
+
+	err := tx.QueryRow(db.Ctx, `
+		SELECT language_code, date_format, sunday_first_dow, font_size, borders_all,
+			borders_corner, page_limit, header_captions, spacing, dark, compact,
+			hint_update_version, mobile_scroll_form, warn_unsaved, menu_colored,
+			pattern, font_family, tab_remember, field_clean
+		FROM instance.login_setting
+		WHERE login_id = $1
+		FOR UPDATE
+	`, loginId).Scan(&s.LanguageCode, &s.DateFormat, &s.SundayFirstDow,
+		&s.FontSize, &s.BordersAll, &s.BordersCorner, &s.PageLimit,
+		&s.HeaderCaptions, &s.Spacing, &s.Dark, &s.Compact, &s.HintUpdateVersion,
+		&s.MobileScrollForm, &s.WarnUnsaved, &s.MenuColored, &s.Pattern,
+		&s.FontFamily, &s.TabRemember, &s.FieldClean)
+
+	return s, err
+}
+
+// getFingerprint computes a SHA-256 hash of the JSON encoding of s, with
+// the Fingerprint field itself cleared. json.Marshal writes struct fields in
+// declaration order, so the hash is stable for a given types.Settings layout
+// but changes if its fields are reordered.
+func getFingerprint(s types.Settings) (string, error) {
+	s.Fingerprint = ""
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
 }
 
 func SetDefaults_tx(tx pgx.Tx, id int64, languageCode string) error {
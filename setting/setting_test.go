@@ -0,0 +1,44 @@
+package setting
+
+import (
+	"r3/types"
+	"testing"
+)
+
+func TestGetFingerprintIsStableAndIgnoresItself(t *testing.T) {
+	a := types.Settings{LanguageCode: "en", FontSize: 100, Fingerprint: "stale"}
+	b := a
+	b.Fingerprint = "different-but-should-not-matter"
+
+	fpA, err := getFingerprint(a)
+	if err != nil {
+		t.Fatalf("getFingerprint returned error: %v", err)
+	}
+	fpB, err := getFingerprint(b)
+	if err != nil {
+		t.Fatalf("getFingerprint returned error: %v", err)
+	}
+
+	if fpA != fpB {
+		t.Fatalf("expected fingerprint to ignore the Fingerprint field itself, got %q vs %q", fpA, fpB)
+	}
+}
+
+func TestGetFingerprintChangesWithContent(t *testing.T) {
+	a := types.Settings{LanguageCode: "en", FontSize: 100}
+	b := a
+	b.FontSize = 120
+
+	fpA, err := getFingerprint(a)
+	if err != nil {
+		t.Fatalf("getFingerprint returned error: %v", err)
+	}
+	fpB, err := getFingerprint(b)
+	if err != nil {
+		t.Fatalf("getFingerprint returned error: %v", err)
+	}
+
+	if fpA == fpB {
+		t.Fatal("expected fingerprint to change when settings content changes")
+	}
+}